@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Config holds the complete exporter configuration as read from the config
+// file plus the decrypted system credentials appended by appendMissingData.
+type Config struct {
+	Secret []byte
+
+	Systems []*systemInfo
+
+	TableMetrics     []*metricInfo          `toml:"metrics"`
+	HistogramMetrics []*histogramMetricInfo `toml:"histogram_metrics"`
+	SummaryMetrics   []*summaryMetricInfo   `toml:"summary_metrics"`
+	Modules          []*moduleInfo          `toml:"modules"`
+
+	// RFC connection pool tuning - see connPool. Left at zero to use the
+	// package defaults.
+	PoolMaxIdle     int    `toml:"pool_max_idle"`
+	PoolMaxLifetime string `toml:"pool_max_lifetime"`
+	PoolIdleTimeout string `toml:"pool_idle_timeout"`
+
+	Defaults defaultsInfo `toml:"defaults"`
+
+	pool   *connPool
+	stale  *staleCache
+	logger *slog.Logger
+}
+
+// defaultsInfo holds config-root fallbacks applied when a metric doesn't
+// set its own value.
+type defaultsInfo struct {
+	// TTL is how long a metric's last good value is kept around and
+	// re-emitted when a scrape fails. "0" (the zero value) disables
+	// caching, which is today's behavior of simply dropping the series.
+	TTL string `toml:"ttl"`
+}
+
+// systemInfo describes one SAP system and, once appendMissingData has run,
+// its decrypted password and the application servers that belong to it.
+type systemInfo struct {
+	Name   string   `toml:"name"`
+	Server string   `toml:"server"`
+	Sysnr  string   `toml:"sysnr"`
+	User   string   `toml:"user"`
+	Client string   `toml:"client"`
+	Lang   string   `toml:"lang"`
+	Usage  string   `toml:"usage"`
+	Tags   []string `toml:"tags"`
+
+	// Cluster is the explicit cluster identity used by aggregationlevel =
+	// "cluster" metrics. It is deliberately separate from Tags, which is
+	// also used for TagFilter matching and is unordered/multi-purpose -
+	// overloading one of its entries as "the" cluster tag would silently
+	// merge any systems that happen to share that tag for unrelated
+	// reasons.
+	Cluster string `toml:"cluster"`
+
+	password string
+	servers  []serverInfo
+}
+
+// serverInfo identifies a single application server of a system.
+type serverInfo struct {
+	name  string
+	sysnr string
+}
+
+// aggregationLevel controls how a metric's observations are rolled up before
+// being handed to Prometheus: per application server, summed across all
+// servers of a system, or summed across every system of the same cluster
+// (see clusterTag).
+type aggregationLevel string
+
+const (
+	aggServer  aggregationLevel = "server"
+	aggSystem  aggregationLevel = "system"
+	aggCluster aggregationLevel = "cluster"
+)
+
+// metricInfo describes a counter/gauge metric built from row occurrences in
+// an RFC table.
+type metricInfo struct {
+	Name       string                   `toml:"name"`
+	Help       string                   `toml:"help"`
+	MetricType string                   `toml:"metrictype"`
+	FuMo       string                   `toml:"fumo"`
+	Params     map[string]interface{}   `toml:"params"`
+	Table      string                   `toml:"table"`
+	RowFilter  map[string][]interface{} `toml:"rowfilter"`
+	RowCount   map[string][]interface{} `toml:"rowcount"`
+	TagFilter  []string                 `toml:"tagfilter"`
+	AllServers bool                     `toml:"allservers"`
+
+	// TTL overrides defaults.ttl for this metric; "0" disables caching.
+	TTL string `toml:"ttl"`
+}
+
+// effectiveTTL resolves the metric's own TTL if it sets one, falling back to
+// defaults.ttl otherwise. A TTL that is 0 or doesn't parse means no caching.
+func (config *Config) effectiveTTL(metric *metricInfo) time.Duration {
+	if metric.TTL != "" {
+		return parseDuration(metric.TTL)
+	}
+	return parseDuration(config.Defaults.TTL)
+}
+
+// histogramMetricInfo describes a histogram metric built from a numeric RFC
+// table field, e.g. a response time column returned by an SDF/MON_* or
+// SWNC_* function module.
+type histogramMetricInfo struct {
+	Name             string                   `toml:"name"`
+	Help             string                   `toml:"help"`
+	FuMo             string                   `toml:"fumo"`
+	Params           map[string]interface{}   `toml:"params"`
+	Table            string                   `toml:"table"`
+	RowFilter        map[string][]interface{} `toml:"rowfilter"`
+	Field            string                   `toml:"field"`
+	Buckets          []float64                `toml:"buckets"`
+	AggregationLevel aggregationLevel         `toml:"aggregationlevel"`
+	TagFilter        []string                 `toml:"tagfilter"`
+	AllServers       bool                     `toml:"allservers"`
+}
+
+// summaryMetricInfo describes a summary metric built from a numeric RFC
+// table field, with quantile targets instead of histogram buckets.
+type summaryMetricInfo struct {
+	Name      string                   `toml:"name"`
+	Help      string                   `toml:"help"`
+	FuMo      string                   `toml:"fumo"`
+	Params    map[string]interface{}   `toml:"params"`
+	Table     string                   `toml:"table"`
+	RowFilter map[string][]interface{} `toml:"rowfilter"`
+	Field     string                   `toml:"field"`
+	// Objectives maps a quantile (as a string, since TOML table keys are
+	// always strings - "0.5", "0.99", ...) to its allowed error margin,
+	// matching the shape Prometheus's own summary configs use.
+	Objectives       map[string]float64 `toml:"objectives"`
+	AggregationLevel aggregationLevel   `toml:"aggregationlevel"`
+	TagFilter        []string           `toml:"tagfilter"`
+	AllServers       bool               `toml:"allservers"`
+}
+
+// aggregationLabels returns the label names/values a sample should carry
+// for the given aggregation level, dropping the server label once servers
+// are being summed together and the system label too once systems sharing
+// a cluster tag are being summed together.
+func aggregationLabels(level aggregationLevel, system *systemInfo, server serverInfo) ([]string, []string) {
+	switch level {
+	case aggSystem:
+		return []string{"system", "usage"}, []string{system.Name, system.Usage}
+	case aggCluster:
+		return []string{"cluster"}, []string{clusterTag(system)}
+	default:
+		return []string{"system", "usage", "server"}, []string{system.Name, system.Usage, server.name}
+	}
+}
+
+// clusterTag returns the cluster a system belongs to. Systems without an
+// explicit cluster fall back to their own name, i.e. a cluster of one.
+func clusterTag(system *systemInfo) string {
+	if system.Cluster == "" {
+		return system.Name
+	}
+	return system.Cluster
+}
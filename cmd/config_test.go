@@ -0,0 +1,45 @@
+package cmd
+
+import "testing"
+
+func TestClusterTagFallsBackToSystemName(t *testing.T) {
+	system := &systemInfo{Name: "PRD", Tags: []string{"hana-cluster-3"}}
+
+	if got := clusterTag(system); got != "PRD" {
+		t.Errorf("clusterTag() = %q, want the system name when Cluster is unset", got)
+	}
+}
+
+func TestClusterTagUsesExplicitCluster(t *testing.T) {
+	system := &systemInfo{Name: "PRD", Tags: []string{"prod"}, Cluster: "hana-cluster-3"}
+
+	if got := clusterTag(system); got != "hana-cluster-3" {
+		t.Errorf("clusterTag() = %q, want the explicit Cluster field, not Tags[0]", got)
+	}
+}
+
+func TestAggregationLabelsCluster(t *testing.T) {
+	system := &systemInfo{Name: "PRD", Cluster: "hana-cluster-3"}
+
+	labels, values := aggregationLabels(aggCluster, system, serverInfo{name: "srv01"})
+
+	if len(labels) != 1 || labels[0] != "cluster" {
+		t.Fatalf("aggregationLabels() labels = %v, want [cluster]", labels)
+	}
+	if len(values) != 1 || values[0] != "hana-cluster-3" {
+		t.Errorf("aggregationLabels() values = %v, want [hana-cluster-3]", values)
+	}
+}
+
+func TestAggregationLabelsServer(t *testing.T) {
+	system := &systemInfo{Name: "PRD", Usage: "prod"}
+
+	labels, values := aggregationLabels(aggServer, system, serverInfo{name: "srv01"})
+
+	if len(labels) != 3 || len(values) != 3 {
+		t.Fatalf("aggregationLabels() = %v/%v, want system/usage/server", labels, values)
+	}
+	if values[2] != "srv01" {
+		t.Errorf("aggregationLabels() server label value = %q, want srv01", values[2])
+	}
+}
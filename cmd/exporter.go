@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 	"sync"
@@ -12,7 +13,6 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sap/gorfc/gorfc"
-	log "github.com/sirupsen/logrus"
 	"github.com/ulranh/sapnwrfc_exporter/internal"
 )
 
@@ -20,8 +20,15 @@ type collector struct {
 	// possible metric descriptions.
 	Desc *prometheus.Desc
 
-	// a parameterized function used to gather metrics.
-	stats func() []metricData
+	// a parameterized function used to gather metrics. Takes the recorder
+	// that should receive this scrape's duration/success observations.
+	stats func(*scrapeRecorder) []metricData
+
+	// a parameterized function used to gather histogram/summary metrics.
+	numericStats func(*scrapeRecorder) []numericMetricData
+
+	// RFC connection pool, exposed as sapnwrfc_pool_* metrics.
+	pool *connPool
 }
 
 type metricData struct {
@@ -37,29 +44,149 @@ type statData struct {
 	labelValues []string
 }
 
+// one observed scrape - either a TH_SERVER_LIST lookup or a metric function
+// module call - used to derive sapnwrfc_scrape_duration_seconds and
+// sapnwrfc_scrape_success. TH_SERVER_LIST lookups only run at startup
+// (appendMissingData) and when /probe resolves an ad hoc target, each with a
+// recorder that Collect never reads, so they don't appear in these metrics.
+type scrapeStat struct {
+	system   string
+	server   string
+	metric   string
+	duration float64
+	success  bool
+}
+
+// scrapeRecorder collects scrapeStat values while a scrape is in flight and
+// tracks whether each system is reachable, so Collect can expose them
+// alongside the regular table metrics.
+type scrapeRecorder struct {
+	mu    sync.Mutex
+	stats []scrapeStat
+	up    map[string]bool
+	stale []staleAge
+}
+
+// staleAge is how old a cached value being re-emitted for a failed scrape
+// is, exposed as sapnwrfc_metric_stale_seconds.
+type staleAge struct {
+	metric string
+	system string
+	server string
+	age    float64
+}
+
+func newScrapeRecorder() *scrapeRecorder {
+	return &scrapeRecorder{
+		up: make(map[string]bool),
+	}
+}
+
+func (r *scrapeRecorder) observe(system, server, metric string, duration float64, success bool) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats = append(r.stats, scrapeStat{
+		system:   system,
+		server:   server,
+		metric:   metric,
+		duration: duration,
+		success:  success,
+	})
+}
+
+func (r *scrapeRecorder) setUp(system string, up bool) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// a system is only marked down if none of its servers answered.
+	if up || !r.up[system] {
+		r.up[system] = up
+	}
+}
+
+// observeStale records that a cached value is being re-emitted in place of
+// a failed scrape, and how old it is.
+func (r *scrapeRecorder) observeStale(system, server, metric string, age time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stale = append(r.stale, staleAge{metric: metric, system: system, server: server, age: age.Seconds()})
+}
+
+// snapshot returns a copy of the current scrape stats and resets them so the
+// next scrape starts from a clean slate.
+func (r *scrapeRecorder) snapshot() ([]scrapeStat, map[string]bool, []staleAge) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := r.stats
+	r.stats = nil
+
+	stale := r.stale
+	r.stale = nil
+
+	up := make(map[string]bool, len(r.up))
+	for system, ok := range r.up {
+		up[system] = ok
+	}
+	return stats, up, stale
+}
+
 // start collector and web server
 func (config *Config) web(flags map[string]*string) error {
 
+	config.logger = newLogger(
+		flagValue(flags, "log.level", "info"),
+		flagValue(flags, "log.format", "logfmt"),
+		flagValue(flags, "log.dedup-window", "1m"),
+	)
+
+	config.pool = newConnPool(config.PoolMaxIdle, parseDuration(config.PoolMaxLifetime), parseDuration(config.PoolIdleTimeout))
+	defer config.pool.close()
+	config.stale = newStaleCache()
+
 	// append missing system data
-	err := config.appendMissingData()
+	err := config.appendMissingData(&scrapeContext{
+		pool:     config.pool,
+		stale:    config.stale,
+		recorder: newScrapeRecorder(),
+		logger:   config.logger,
+		scrapeID: nextScrapeID(),
+	})
 	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err,
-		}).Error("Can't add missing config data.")
+		config.logger.Error("Can't add missing config data", "error", err)
 		return err
 	}
 
-	stats := func() []metricData {
-		data := config.collectMetrics()
+	// stats/numericStats are invoked once per Collect call with a recorder
+	// scoped to that single scrape - see collector.Collect - so concurrent
+	// scrapes never share one.
+	stats := func(recorder *scrapeRecorder) []metricData {
+		return config.collectMetrics(config.newScrapeContext(recorder))
+	}
+	numericStats := func(recorder *scrapeRecorder) []numericMetricData {
+		sc := config.newScrapeContext(recorder)
+		var data []numericMetricData
+		data = append(data, config.collectHistogramMetrics(sc)...)
+		data = append(data, config.collectSummaryMetrics(sc)...)
 		return data
 	}
 
-	c := newCollector(stats)
+	c := newCollector(stats, numericStats, config.pool)
 	prometheus.MustRegister(c)
 
 	// start http server
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/probe", config.probeHandler)
 	server := &http.Server{
 		Addr:         ":" + *flags["port"],
 		Handler:      mux,
@@ -74,70 +201,97 @@ func (config *Config) web(flags map[string]*string) error {
 	return nil
 }
 
-// append system password and system servers to config.Systems
-func (config *Config) appendMissingData() error {
-	var secret internal.Secret
-	if err := proto.Unmarshal(config.Secret, &secret); err != nil {
-		log.Fatal("Secret Values don't exist or are corrupted")
-		return errors.Wrap(err, " system  - Unmarshal")
+// newScrapeContext wraps the long-lived pool/stale-cache/recorder/logger
+// with a fresh scrape_id, so every log line produced while collecting one
+// scrape can be correlated.
+func (config *Config) newScrapeContext(recorder *scrapeRecorder) *scrapeContext {
+	return &scrapeContext{
+		pool:     config.pool,
+		stale:    config.stale,
+		recorder: recorder,
+		logger:   config.logger,
+		scrapeID: nextScrapeID(),
 	}
+}
 
+// append system password and system servers to config.Systems
+func (config *Config) appendMissingData(sc *scrapeContext) error {
 	for _, system := range config.Systems {
 
 		// decrypt password and add it to system config
-		if _, ok := secret.Name[system.Name]; !ok {
-			log.WithFields(log.Fields{
-				"system": system.Name,
-			}).Error("Can't find password for system")
-			continue
-		}
-		pw, err := internal.PwDecrypt(secret.Name[system.Name], secret.Name["secretkey"])
+		pw, err := config.lookupPassword(system.Name)
 		if err != nil {
-			log.WithFields(log.Fields{
-				"system": system.Name,
-			}).Error("Can't decrypt password for system")
+			sc.logger.Error("Can't find password for system", "system", system.Name, "error", err)
 			continue
 		}
 		system.password = pw
 
 		// retrieve system servers and add them to config
-		c, err := connect(system, serverInfo{system.Server, system.Sysnr})
-		if err != nil {
+		if err := system.resolveServers(sc); err != nil {
 			continue
 		}
-		defer c.Close()
+	}
+	return nil
+}
 
-		params := map[string]interface{}{}
-		r, err := c.Call("TH_SERVER_LIST", params)
-		if err != nil {
-			log.WithFields(log.Fields{
-				"system": system.Name,
-				"error":  err,
-			}).Error("Can't call fumo th_server_list")
-			continue
-		}
+// lookupPassword decrypts the password of the given system from the
+// encrypted Secret store.
+func (config *Config) lookupPassword(name string) (string, error) {
+	var secret internal.Secret
+	if err := proto.Unmarshal(config.Secret, &secret); err != nil {
+		config.logger.Error("Secret values don't exist or are corrupted")
+		return "", errors.Wrap(err, " system  - Unmarshal")
+	}
 
-		for _, v := range r["LIST"].([]interface{}) {
-			appl := v.(map[string]interface{})
-			info := strings.Split(strings.TrimSpace(appl["NAME"].(string)), "_")
-			server := serverInfo{
-				name:  strings.TrimSpace(info[0]),
-				sysnr: strings.TrimSpace(info[2]),
-			}
-			system.servers = append(system.servers, server)
+	if _, ok := secret.Name[name]; !ok {
+		return "", errors.Errorf("no password found for system %q", name)
+	}
+	pw, err := internal.PwDecrypt(secret.Name[name], secret.Name["secretkey"])
+	if err != nil {
+		return "", errors.Wrap(err, " system - PwDecrypt")
+	}
+	return pw, nil
+}
+
+// resolveServers calls TH_SERVER_LIST against the system's default server
+// and fills in the application servers belonging to it.
+func (system *systemInfo) resolveServers(sc *scrapeContext) error {
+	start := time.Now()
+	server := serverInfo{system.Server, system.Sysnr}
+	c, err := sc.pool.Get(system, server, sc.logger)
+	if err != nil {
+		sc.recorder.setUp(system.Name, false)
+		sc.recorder.observe(system.Name, system.Server, "TH_SERVER_LIST", time.Since(start).Seconds(), false)
+		return err
+	}
 
+	params := map[string]interface{}{}
+	r, err := c.Call("TH_SERVER_LIST", params)
+	sc.recorder.observe(system.Name, system.Server, "TH_SERVER_LIST", time.Since(start).Seconds(), err == nil)
+	sc.recorder.setUp(system.Name, err == nil)
+	if err != nil {
+		sc.pool.Discard(c)
+		sc.logger.Error("Can't call fumo th_server_list",
+			"system", system.Name, "server", system.Server, "sysnr", system.Sysnr,
+			"fumo", "TH_SERVER_LIST", "scrape_id", sc.scrapeID, "error", err)
+		return err
+	}
+	sc.pool.Put(c)
+
+	for _, v := range r["LIST"].([]interface{}) {
+		appl := v.(map[string]interface{})
+		info := strings.Split(strings.TrimSpace(appl["NAME"].(string)), "_")
+		server := serverInfo{
+			name:  strings.TrimSpace(info[0]),
+			sysnr: strings.TrimSpace(info[2]),
 		}
+		system.servers = append(system.servers, server)
 	}
 	return nil
 }
 
 // start collecting all metrics and fetch the results
-func (config *Config) collectMetrics() []metricData {
-
-	// start := time.Now()
-	// log.WithFields(log.Fields{
-	// 	"timestamp": start,
-	// }).Info("Start scraping")
+func (config *Config) collectMetrics(sc *scrapeContext) []metricData {
 
 	resC := make(chan metricData)
 	go func(metrics []*metricInfo, systems []*systemInfo) {
@@ -152,7 +306,7 @@ func (config *Config) collectMetrics() []metricData {
 					name:       metric.Name,
 					help:       metric.Help,
 					metricType: metric.MetricType,
-					stats:      collectSystemsMetric(metric, systems),
+					stats:      collectSystemsMetric(metric, systems, config.effectiveTTL(metric), sc),
 				}
 			}(metric, systems)
 		}
@@ -165,14 +319,11 @@ func (config *Config) collectMetrics() []metricData {
 		metrics = append(metrics, metric)
 	}
 
-	// log.WithFields(log.Fields{
-	// 	"timestamp": time.Since(start),
-	// }).Info("Finish scraping")
 	return metrics
 }
 
 // start collecting metric information for all tenants
-func collectSystemsMetric(metric *metricInfo, systems []*systemInfo) []statData {
+func collectSystemsMetric(metric *metricInfo, systems []*systemInfo, ttl time.Duration, sc *scrapeContext) []statData {
 	resC := make(chan []statData)
 
 	go func(metric *metricInfo, systems []*systemInfo) {
@@ -184,7 +335,7 @@ func collectSystemsMetric(metric *metricInfo, systems []*systemInfo) []statData
 			go func(metric *metricInfo, system *systemInfo) {
 				defer wg.Done()
 
-				resC <- getMetricSystemData(metric, system)
+				resC <- getMetricSystemData(metric, system, ttl, sc)
 			}(metric, system)
 		}
 		wg.Wait()
@@ -201,7 +352,7 @@ func collectSystemsMetric(metric *metricInfo, systems []*systemInfo) []statData
 }
 
 // get metric data for all systems application servers
-func getMetricSystemData(metric *metricInfo, system *systemInfo) []statData {
+func getMetricSystemData(metric *metricInfo, system *systemInfo, ttl time.Duration, sc *scrapeContext) []statData {
 
 	resC := make(chan []statData)
 	go func(metric *metricInfo, system *systemInfo) {
@@ -212,7 +363,7 @@ func getMetricSystemData(metric *metricInfo, system *systemInfo) []statData {
 			wg.Add(1)
 			go func(metric *metricInfo, system *systemInfo, server serverInfo) {
 				defer wg.Done()
-				resC <- getRfcData(metric, system, server)
+				resC <- getRfcData(metric, system, server, ttl, sc)
 			}(metric, system, server)
 
 			// stop if fumo must be called only once
@@ -236,38 +387,59 @@ func getMetricSystemData(metric *metricInfo, system *systemInfo) []statData {
 type rfcData map[string]interface{}
 
 // get rfc data from sap system
-func getRfcData(metric *metricInfo, system *systemInfo, server serverInfo) []statData {
+func getRfcData(metric *metricInfo, system *systemInfo, server serverInfo, ttl time.Duration, sc *scrapeContext) []statData {
+
+	start := time.Now()
+	key := staleKey{metric: metric.Name, system: system.Name, server: server.name}
 
 	// connect to system/server
-	c, err := connect(system, server)
+	c, err := sc.pool.Get(system, server, sc.logger)
 	if err != nil {
-		return nil
+		sc.recorder.observe(system.Name, server.name, metric.Name, time.Since(start).Seconds(), false)
+		sc.recorder.setUp(system.Name, false)
+		return staleOrNil(sc, key, ttl)
 	}
-	defer c.Close()
 
 	// all values of Metrics.TagFilter must be in Tenants.Tags, otherwise the
 	// metric is not relevant for the tenant
 	if !subSliceInSlice(metric.TagFilter, system.Tags) {
+		sc.pool.Put(c)
 		return nil
 	}
 
 	// call metrics function module
 	var res rfcData
 	res, err = c.Call(metric.FuMo, metric.Params)
+	sc.recorder.observe(system.Name, server.name, metric.Name, time.Since(start).Seconds(), err == nil)
+	sc.recorder.setUp(system.Name, err == nil)
 	if err != nil {
-		log.WithFields(log.Fields{
-			"system": system.Name,
-			"server": server.name,
-			"error":  err,
-		}).Error("Can't call function module")
-		return nil
+		sc.pool.Discard(c)
+		sc.logger.Error("Can't call function module",
+			"system", system.Name, "server", server.name, "sysnr", server.sysnr,
+			"metric", metric.Name, "fumo", metric.FuMo, "scrape_id", sc.scrapeID, "error", err)
+		return staleOrNil(sc, key, ttl)
 	}
+	sc.pool.Put(c)
+
+	stats := res.collectTableData(metric, system, server, sc)
+	sc.stale.put(key, stats, ttl)
+	return stats
+}
 
-	return res.collectTableData(metric, system, server)
+// staleOrNil re-emits the last good value for key if it's still within its
+// TTL, recording how stale it is, otherwise it returns nil - the series
+// disappearing, same as before TTLs existed.
+func staleOrNil(sc *scrapeContext, key staleKey, ttl time.Duration) []statData {
+	stats, age, ok := sc.stale.get(key, ttl)
+	if !ok {
+		return nil
+	}
+	sc.recorder.observeStale(key.system, key.server, key.metric, age)
+	return stats
 }
 
 // get table information - occurrences of specified table field values
-func (tableData rfcData) collectTableData(metric *metricInfo, system *systemInfo, server serverInfo) []statData {
+func (tableData rfcData) collectTableData(metric *metricInfo, system *systemInfo, server serverInfo, sc *scrapeContext) []statData {
 
 	var md []statData
 	count := make(map[string]float64)
@@ -280,10 +452,8 @@ func (tableData rfcData) collectTableData(metric *metricInfo, system *systemInfo
 				for _, value := range values {
 					namePart := interface2String(value)
 					if "" == namePart {
-						log.WithFields(log.Fields{
-							"metric": metric.Name,
-							"system": system.Name,
-						}).Error("Configfile RowCount: only string and int types are allowed")
+						sc.logger.Error("Configfile RowCount: only string and int types are allowed",
+							"metric", metric.Name, "system", system.Name, "scrape_id", sc.scrapeID)
 						continue
 					}
 
@@ -337,9 +507,11 @@ func interface2String(namePart interface{}) string {
 	}
 }
 
-func newCollector(stats func() []metricData) *collector {
+func newCollector(stats func(*scrapeRecorder) []metricData, numericStats func(*scrapeRecorder) []numericMetricData, pool *connPool) *collector {
 	return &collector{
-		stats: stats,
+		stats:        stats,
+		numericStats: numericStats,
+		pool:         pool,
 	}
 }
 
@@ -350,8 +522,15 @@ func (c *collector) Describe(ch chan<- *prometheus.Desc) {
 
 // Collect implements prometheus.Collector.
 func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	// A fresh recorder per Collect call: Gather() releases its lock before
+	// calling Collect, so two overlapping scrapes (two Prometheus
+	// replicas, or a slow scrape overlapping the next interval) must not
+	// share a recorder, otherwise one scrape's duration/success/stale
+	// samples can be attributed to the other.
+	recorder := newScrapeRecorder()
+
 	// Take a stats snapshot.  Must be concurrency safe.
-	stats := c.stats()
+	stats := c.stats(recorder)
 
 	var valueType = map[string]prometheus.ValueType{
 		"gauge":   prometheus.GaugeValue,
@@ -368,10 +547,108 @@ func (c *collector) Collect(ch chan<- prometheus.Metric) {
 			ch <- m
 		}
 	}
+
+	for _, mi := range c.numericStats(recorder) {
+		for _, s := range mi.samples {
+			desc := prometheus.NewDesc(strings.ToLower(mi.name), mi.help, s.labels, nil)
+
+			switch mi.metricType {
+			case "histogram":
+				buckets := make(map[float64]uint64, len(mi.buckets))
+				var sum float64
+				for _, v := range s.values {
+					sum += v
+					for _, b := range mi.buckets {
+						if v <= b {
+							buckets[b]++
+						}
+					}
+				}
+				ch <- prometheus.MustNewConstHistogram(desc, uint64(len(s.values)), sum, buckets, s.labelValues...)
+			case "summary":
+				quantiles := make(map[float64]float64, len(mi.objectives))
+				var sum float64
+				for _, v := range s.values {
+					sum += v
+				}
+				for q := range mi.objectives {
+					quantiles[q] = quantile(s.values, q)
+				}
+				ch <- prometheus.MustNewConstSummary(desc, uint64(len(s.values)), sum, quantiles, s.labelValues...)
+			}
+		}
+	}
+
+	// per-scrape observability: how long each system/server/metric call
+	// took, whether it succeeded, and whether the system answered at all.
+	scrapeStats, up, stale := recorder.snapshot()
+	for _, s := range scrapeStats {
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc("sapnwrfc_scrape_duration_seconds", "Duration of a scrape call in seconds", []string{"system", "server", "metric"}, nil),
+			prometheus.GaugeValue,
+			s.duration,
+			s.system, s.server, s.metric,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc("sapnwrfc_scrape_success", "Whether a scrape call succeeded", []string{"system", "server", "metric"}, nil),
+			prometheus.GaugeValue,
+			bool2Float(s.success),
+			s.system, s.server, s.metric,
+		)
+	}
+	for system, ok := range up {
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc("sapnwrfc_up", "Whether the last scrape of the system succeeded", []string{"system"}, nil),
+			prometheus.GaugeValue,
+			bool2Float(ok),
+			system,
+		)
+	}
+	for _, s := range stale {
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc("sapnwrfc_metric_stale_seconds", "Age of a metric value re-emitted from cache after a failed scrape", []string{"system", "server", "metric"}, nil),
+			prometheus.GaugeValue,
+			s.age,
+			s.system, s.server, s.metric,
+		)
+	}
+
+	if c.pool == nil {
+		return
+	}
+	ps := c.pool.stats()
+	ch <- prometheus.MustNewConstMetric(
+		prometheus.NewDesc("sapnwrfc_pool_open", "Number of RFC connections currently open, idle or checked out", nil, nil),
+		prometheus.GaugeValue,
+		float64(ps.open),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		prometheus.NewDesc("sapnwrfc_pool_reused_total", "Total number of RFC connections reused from the pool", nil, nil),
+		prometheus.CounterValue,
+		float64(ps.reused),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		prometheus.NewDesc("sapnwrfc_pool_created_total", "Total number of RFC connections opened", nil, nil),
+		prometheus.CounterValue,
+		float64(ps.created),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		prometheus.NewDesc("sapnwrfc_pool_errors_total", "Total number of RFC connection errors", nil, nil),
+		prometheus.CounterValue,
+		float64(ps.errors),
+	)
+}
+
+// convert a bool to a prometheus-friendly 0/1 float
+func bool2Float(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
 }
 
 // connect to sap system
-func connect(system *systemInfo, server serverInfo) (*gorfc.Connection, error) {
+func connect(system *systemInfo, server serverInfo, logger *slog.Logger) (*gorfc.Connection, error) {
 	c, err := gorfc.ConnectionFromParams(
 		gorfc.ConnectionParameter{
 			Dest:   system.Name,
@@ -388,11 +665,8 @@ func connect(system *systemInfo, server serverInfo) (*gorfc.Connection, error) {
 		},
 	)
 	if err != nil {
-		log.WithFields(log.Fields{
-			"system": system.Name,
-			"server": server.name,
-			"error":  err,
-		}).Error("Can't connect to system with user/password")
+		logger.Error("Can't connect to system with user/password",
+			"system", system.Name, "server", server.name, "sysnr", server.sysnr, "error", err)
 		return nil, err
 	}
 
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// newLogger builds the exporter's slog.Logger from the --log.level and
+// --log.format flags, wrapping it with a dedup handler so a persistently
+// down system doesn't spam identical log lines every scrape.
+func newLogger(level, format, dedupWindow string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: parseLevel(level)}
+
+	var handler slog.Handler
+	if strings.EqualFold(format, "json") {
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	}
+
+	if d := parseDuration(dedupWindow); d > 0 {
+		handler = newDedupHandler(handler, d)
+	}
+	return slog.New(handler)
+}
+
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// dedupIdentityAttrs are the record attrs that make up a log line's
+// "identity" for dedup purposes. Attrs outside this allow-list (notably
+// scrape_id, which is unique on every single scrape by construction) are
+// excluded from the dedup key, otherwise a persistently failing
+// system/metric would never match a prior key and the window would never
+// suppress anything.
+var dedupIdentityAttrs = map[string]bool{
+	"system": true,
+	"server": true,
+	"metric": true,
+	"fumo":   true,
+	"error":  true,
+}
+
+// dedupState is shared across every handler derived from the same logger
+// via With/WithGroup, so the suppression window applies no matter which
+// attrs were attached along the way.
+type dedupState struct {
+	mu     sync.Mutex
+	seen   map[string]time.Time
+	window time.Duration
+}
+
+// evictExpired drops entries whose window has already lapsed so a
+// long-running exporter watching a degraded system doesn't grow this map
+// forever. Must be called with mu held.
+func (s *dedupState) evictExpired(now time.Time) {
+	for k, last := range s.seen {
+		if now.Sub(last) >= s.window {
+			delete(s.seen, k)
+		}
+	}
+}
+
+type dedupHandler struct {
+	next  slog.Handler
+	state *dedupState
+}
+
+func newDedupHandler(next slog.Handler, window time.Duration) slog.Handler {
+	return &dedupHandler{next: next, state: &dedupState{seen: make(map[string]time.Time), window: window}}
+}
+
+func (h *dedupHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *dedupHandler) Handle(ctx context.Context, r slog.Record) error {
+	var key strings.Builder
+	key.WriteString(r.Message)
+	r.Attrs(func(a slog.Attr) bool {
+		if !dedupIdentityAttrs[a.Key] {
+			return true
+		}
+		key.WriteString("|")
+		key.WriteString(a.Key)
+		key.WriteString("=")
+		key.WriteString(a.Value.String())
+		return true
+	})
+
+	now := time.Now()
+	h.state.mu.Lock()
+	h.state.evictExpired(now)
+	last, seen := h.state.seen[key.String()]
+	suppress := seen && now.Sub(last) < h.state.window
+	if !suppress {
+		h.state.seen[key.String()] = now
+	}
+	h.state.mu.Unlock()
+
+	if suppress {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *dedupHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &dedupHandler{next: h.next.WithAttrs(attrs), state: h.state}
+}
+
+func (h *dedupHandler) WithGroup(name string) slog.Handler {
+	return &dedupHandler{next: h.next.WithGroup(name), state: h.state}
+}
+
+// scrapeCounter generates the scrape_id attribute correlating every log
+// line produced by one scrape.
+var scrapeCounter uint64
+
+func nextScrapeID() string {
+	return strconv.FormatUint(atomic.AddUint64(&scrapeCounter, 1), 10)
+}
+
+// scrapeContext bundles the state that needs to reach every RFC call of a
+// scrape - the connection pool, the stale-value cache, the scrape
+// timing/success recorder and the logger - so passing it around doesn't
+// mean growing every function's parameter list each time one more of these
+// is added.
+type scrapeContext struct {
+	pool     *connPool
+	stale    *staleCache
+	recorder *scrapeRecorder
+	logger   *slog.Logger
+	scrapeID string
+}
+
+// flagValue returns the value of a CLI flag, or def if it wasn't set.
+func flagValue(flags map[string]*string, key, def string) string {
+	if v, ok := flags[key]; ok && v != nil && *v != "" {
+		return *v
+	}
+	return def
+}
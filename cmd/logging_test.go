@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// testLogger returns a *slog.Logger that discards its output, for tests
+// that only care about the value returned by the function under test.
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func newTestDedupHandler(buf *bytes.Buffer, window time.Duration) *dedupHandler {
+	next := slog.NewTextHandler(buf, nil)
+	return newDedupHandler(next, window).(*dedupHandler)
+}
+
+func logRecord(msg string, attrs ...slog.Attr) slog.Record {
+	r := slog.NewRecord(time.Now(), slog.LevelError, msg, 0)
+	r.AddAttrs(attrs...)
+	return r
+}
+
+func TestDedupHandlerSuppressesRepeatWithinWindow(t *testing.T) {
+	var buf bytes.Buffer
+	h := newTestDedupHandler(&buf, time.Minute)
+
+	record := logRecord("Can't call function module", slog.String("system", "PRD"), slog.String("metric", "resp_time"))
+
+	if err := h.Handle(context.Background(), record); err != nil {
+		t.Fatalf("first Handle() error = %v", err)
+	}
+	if err := h.Handle(context.Background(), record); err != nil {
+		t.Fatalf("second Handle() error = %v", err)
+	}
+
+	if got := bytes.Count(buf.Bytes(), []byte("Can't call function module")); got != 1 {
+		t.Errorf("line printed %d times within the dedup window, want 1", got)
+	}
+}
+
+func TestDedupHandlerIgnoresScrapeID(t *testing.T) {
+	var buf bytes.Buffer
+	h := newTestDedupHandler(&buf, time.Minute)
+
+	for _, scrapeID := range []string{"1", "2", "3"} {
+		record := logRecord("Can't call function module",
+			slog.String("system", "PRD"), slog.String("metric", "resp_time"), slog.String("scrape_id", scrapeID))
+		if err := h.Handle(context.Background(), record); err != nil {
+			t.Fatalf("Handle() error = %v", err)
+		}
+	}
+
+	if got := bytes.Count(buf.Bytes(), []byte("Can't call function module")); got != 1 {
+		t.Errorf("line printed %d times across scrape_ids, want 1 - scrape_id must not break dedup", got)
+	}
+}
+
+func TestDedupHandlerEmitsAgainAfterWindow(t *testing.T) {
+	var buf bytes.Buffer
+	h := newTestDedupHandler(&buf, time.Millisecond)
+
+	record := logRecord("Can't call function module", slog.String("system", "PRD"))
+
+	if err := h.Handle(context.Background(), record); err != nil {
+		t.Fatalf("first Handle() error = %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err := h.Handle(context.Background(), record); err != nil {
+		t.Fatalf("second Handle() error = %v", err)
+	}
+
+	if got := bytes.Count(buf.Bytes(), []byte("Can't call function module")); got != 2 {
+		t.Errorf("line printed %d times after window elapsed, want 2", got)
+	}
+}
+
+func TestDedupStateEvictsExpiredEntries(t *testing.T) {
+	state := &dedupState{seen: make(map[string]time.Time), window: time.Millisecond}
+	state.seen["stale"] = time.Now().Add(-time.Hour)
+	state.seen["fresh"] = time.Now()
+
+	state.evictExpired(time.Now())
+
+	if _, ok := state.seen["stale"]; ok {
+		t.Errorf("evictExpired() kept an entry past its window")
+	}
+	if _, ok := state.seen["fresh"]; !ok {
+		t.Errorf("evictExpired() dropped an entry still within its window")
+	}
+}
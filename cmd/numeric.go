@@ -0,0 +1,273 @@
+package cmd
+
+import (
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// numericMetricSpec is the subset of a histogramMetricInfo/summaryMetricInfo
+// needed to pull raw field values out of an RFC table - everything the two
+// metric kinds have in common.
+type numericMetricSpec struct {
+	FuMo             string
+	Params           map[string]interface{}
+	Table            string
+	RowFilter        map[string][]interface{}
+	Field            string
+	AggregationLevel aggregationLevel
+	TagFilter        []string
+	AllServers       bool
+}
+
+func (m *histogramMetricInfo) spec() numericMetricSpec {
+	return numericMetricSpec{
+		FuMo:             m.FuMo,
+		Params:           m.Params,
+		Table:            m.Table,
+		RowFilter:        m.RowFilter,
+		Field:            m.Field,
+		AggregationLevel: m.AggregationLevel,
+		TagFilter:        m.TagFilter,
+		AllServers:       m.AllServers,
+	}
+}
+
+func (m *summaryMetricInfo) spec() numericMetricSpec {
+	return numericMetricSpec{
+		FuMo:             m.FuMo,
+		Params:           m.Params,
+		Table:            m.Table,
+		RowFilter:        m.RowFilter,
+		Field:            m.Field,
+		AggregationLevel: m.AggregationLevel,
+		TagFilter:        m.TagFilter,
+		AllServers:       m.AllServers,
+	}
+}
+
+// numericSample is one group of raw field observations sharing the same
+// labels, after aggregation across servers/systems has been applied.
+type numericSample struct {
+	labels      []string
+	labelValues []string
+	values      []float64
+}
+
+// numericMetricData is a fully collected histogram or summary metric, ready
+// to be turned into a prometheus.MustNewConstHistogram/ConstSummary in
+// Collect.
+type numericMetricData struct {
+	name       string
+	help       string
+	metricType string
+	buckets    []float64
+	objectives map[float64]float64
+	samples    []numericSample
+}
+
+// collectHistogramMetrics gathers every configured histogram metric.
+func (config *Config) collectHistogramMetrics(sc *scrapeContext) []numericMetricData {
+	var data []numericMetricData
+	for _, metric := range config.HistogramMetrics {
+		data = append(data, numericMetricData{
+			name:       metric.Name,
+			help:       metric.Help,
+			metricType: "histogram",
+			buckets:    metric.Buckets,
+			samples:    collectNumericMetric(metric.spec(), config.Systems, sc),
+		})
+	}
+	return data
+}
+
+// collectSummaryMetrics gathers every configured summary metric.
+func (config *Config) collectSummaryMetrics(sc *scrapeContext) []numericMetricData {
+	var data []numericMetricData
+	for _, metric := range config.SummaryMetrics {
+		data = append(data, numericMetricData{
+			name:       metric.Name,
+			help:       metric.Help,
+			metricType: "summary",
+			objectives: parseObjectives(metric.Objectives, sc.logger),
+			samples:    collectNumericMetric(metric.spec(), config.Systems, sc),
+		})
+	}
+	return data
+}
+
+// collectNumericMetric fetches the raw field values for one histogram or
+// summary metric across every system/server and aggregates them according
+// to its AggregationLevel.
+func collectNumericMetric(spec numericMetricSpec, systems []*systemInfo, sc *scrapeContext) []numericSample {
+	resC := make(chan numericSample)
+
+	go func(systems []*systemInfo) {
+		var wg sync.WaitGroup
+		for _, system := range systems {
+			if !subSliceInSlice(spec.TagFilter, system.Tags) {
+				continue
+			}
+
+			for _, server := range system.servers {
+				wg.Add(1)
+				go func(system *systemInfo, server serverInfo) {
+					defer wg.Done()
+
+					values := getNumericRfcData(spec, system, server, sc)
+					if values == nil {
+						return
+					}
+					labels, labelValues := aggregationLabels(spec.AggregationLevel, system, server)
+					resC <- numericSample{labels: labels, labelValues: labelValues, values: values}
+				}(system, server)
+
+				// stop if fumo must be called only once
+				if !spec.AllServers {
+					break
+				}
+			}
+		}
+		wg.Wait()
+		close(resC)
+	}(systems)
+
+	var samples []numericSample
+	for s := range resC {
+		samples = append(samples, s)
+	}
+	return mergeNumericSamples(samples)
+}
+
+// mergeNumericSamples combines samples that share the same aggregated
+// labels - e.g. every server of a system when AggregationLevel is "system",
+// or every system of a cluster when it is "cluster" - into a single sample
+// whose values are the union of all observations.
+func mergeNumericSamples(samples []numericSample) []numericSample {
+	index := make(map[string]int)
+	var merged []numericSample
+
+	for _, s := range samples {
+		key := strings.Join(s.labelValues, "\x00")
+		if i, ok := index[key]; ok {
+			merged[i].values = append(merged[i].values, s.values...)
+			continue
+		}
+		index[key] = len(merged)
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// getNumericRfcData calls the metric's function module and extracts the
+// numeric field values of every matching table row.
+func getNumericRfcData(spec numericMetricSpec, system *systemInfo, server serverInfo, sc *scrapeContext) []float64 {
+	start := time.Now()
+
+	c, err := sc.pool.Get(system, server, sc.logger)
+	if err != nil {
+		sc.recorder.observe(system.Name, server.name, spec.Table, time.Since(start).Seconds(), false)
+		sc.recorder.setUp(system.Name, false)
+		return nil
+	}
+
+	var res rfcData
+	res, err = c.Call(spec.FuMo, spec.Params)
+	sc.recorder.observe(system.Name, server.name, spec.Table, time.Since(start).Seconds(), err == nil)
+	sc.recorder.setUp(system.Name, err == nil)
+	if err != nil {
+		sc.pool.Discard(c)
+		sc.logger.Error("Can't call function module",
+			"system", system.Name, "server", server.name, "sysnr", server.sysnr,
+			"fumo", spec.FuMo, "scrape_id", sc.scrapeID, "error", err)
+		return nil
+	}
+	sc.pool.Put(c)
+
+	rows, ok := res[spec.Table].([]interface{})
+	if !ok {
+		sc.logger.Error("Configfile table: table not found in RFC response",
+			"system", system.Name, "server", server.name, "fumo", spec.FuMo,
+			"table", spec.Table, "scrape_id", sc.scrapeID)
+		return nil
+	}
+
+	var values []float64
+	for _, row := range rows {
+		line := row.(map[string]interface{})
+		if len(spec.RowFilter) > 0 && !inFilter(line, spec.RowFilter) {
+			continue
+		}
+
+		switch v := line[strings.ToUpper(spec.Field)].(type) {
+		case float64:
+			values = append(values, v)
+		case float32:
+			values = append(values, float64(v))
+		case int64, int32, int16, int8, int, uint64, uint32, uint8, uint:
+			values = append(values, interface2Float(v))
+		}
+	}
+	return values
+}
+
+// parseObjectives converts a summary metric's TOML-decoded objectives table
+// (string keys, since TOML table keys can never be floats) into the
+// quantile->error-margin map used downstream. Keys that don't parse as a
+// float are logged and skipped rather than failing the whole metric.
+func parseObjectives(objectives map[string]float64, logger *slog.Logger) map[float64]float64 {
+	if len(objectives) == 0 {
+		return nil
+	}
+	parsed := make(map[float64]float64, len(objectives))
+	for k, v := range objectives {
+		q, err := strconv.ParseFloat(k, 64)
+		if err != nil {
+			logger.Error("Configfile summary_metrics: objectives key is not a valid quantile", "key", k, "error", err)
+			continue
+		}
+		parsed[q] = v
+	}
+	return parsed
+}
+
+// quantile returns the q-quantile (0 <= q <= 1) of values as the element at
+// index floor(q*(len(values)-1)) of the sorted values, without interpolation.
+// values is sorted in place.
+func quantile(values []float64, q float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	rank := int(q * float64(len(values)-1))
+	return values[rank]
+}
+
+// interface2Float converts an RFC integer value to a float64.
+func interface2Float(v interface{}) float64 {
+	switch val := v.(type) {
+	case int64:
+		return float64(val)
+	case int32:
+		return float64(val)
+	case int16:
+		return float64(val)
+	case int8:
+		return float64(val)
+	case int:
+		return float64(val)
+	case uint64:
+		return float64(val)
+	case uint32:
+		return float64(val)
+	case uint8:
+		return float64(val)
+	case uint:
+		return float64(val)
+	default:
+		return 0
+	}
+}
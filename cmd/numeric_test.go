@@ -0,0 +1,78 @@
+package cmd
+
+import "testing"
+
+func TestQuantile(t *testing.T) {
+	cases := []struct {
+		name   string
+		values []float64
+		q      float64
+		want   float64
+	}{
+		{"empty", nil, 0.5, 0},
+		{"single value", []float64{42}, 0.99, 42},
+		{"median of five", []float64{5, 1, 4, 2, 3}, 0.5, 3},
+		{"p0 is the minimum", []float64{5, 1, 4, 2, 3}, 0, 1},
+		{"p100 is the maximum", []float64{5, 1, 4, 2, 3}, 1, 5},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := quantile(c.values, c.q); got != c.want {
+				t.Errorf("quantile(%v, %v) = %v, want %v", c.values, c.q, got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseObjectives(t *testing.T) {
+	objectives := map[string]float64{"0.5": 0.05, "0.99": 0.001}
+
+	got := parseObjectives(objectives, testLogger())
+	if len(got) != 2 {
+		t.Fatalf("parseObjectives() = %v, want 2 entries", got)
+	}
+	if got[0.5] != 0.05 || got[0.99] != 0.001 {
+		t.Errorf("parseObjectives() = %v, want quantile keys parsed as floats", got)
+	}
+}
+
+func TestParseObjectivesSkipsInvalidKeys(t *testing.T) {
+	got := parseObjectives(map[string]float64{"not-a-number": 0.01, "0.9": 0.01}, testLogger())
+
+	if len(got) != 1 {
+		t.Fatalf("parseObjectives() = %v, want only the valid entry", got)
+	}
+}
+
+func TestMergeNumericSamplesCombinesSharedLabels(t *testing.T) {
+	samples := []numericSample{
+		{labels: []string{"cluster"}, labelValues: []string{"prod"}, values: []float64{1, 2}},
+		{labels: []string{"cluster"}, labelValues: []string{"prod"}, values: []float64{3}},
+		{labels: []string{"cluster"}, labelValues: []string{"staging"}, values: []float64{9}},
+	}
+
+	merged := mergeNumericSamples(samples)
+
+	if len(merged) != 2 {
+		t.Fatalf("mergeNumericSamples() = %d samples, want 2", len(merged))
+	}
+
+	byCluster := make(map[string][]float64, len(merged))
+	for _, s := range merged {
+		byCluster[s.labelValues[0]] = s.values
+	}
+
+	if got := byCluster["prod"]; len(got) != 3 {
+		t.Errorf("merged prod values = %v, want 3 values from both samples", got)
+	}
+	if got := byCluster["staging"]; len(got) != 1 {
+		t.Errorf("merged staging values = %v, want 1 value", got)
+	}
+}
+
+func TestMergeNumericSamplesNoop(t *testing.T) {
+	if merged := mergeNumericSamples(nil); merged != nil {
+		t.Errorf("mergeNumericSamples(nil) = %v, want nil", merged)
+	}
+}
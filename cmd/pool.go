@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/sap/gorfc/gorfc"
+)
+
+const (
+	defaultPoolMaxIdle     = 2
+	defaultPoolMaxLifetime = 30 * time.Minute
+	defaultPoolIdleTimeout = 5 * time.Minute
+)
+
+// poolKey identifies one application server, the unit a connection is tied
+// to and reused for.
+type poolKey struct {
+	system string
+	server string
+	sysnr  string
+}
+
+// pooledConn wraps an idle connection with the bookkeeping needed to expire
+// it.
+type pooledConn struct {
+	conn      *gorfc.Connection
+	key       poolKey
+	createdAt time.Time
+	idleSince time.Time
+}
+
+// connPool keeps idle RFC connections around across scrapes so that a
+// config with N metrics x M servers doesn't open a fresh logon for every
+// (metric, system, server) triple on every scrape. Connections are reused
+// while younger than maxLifetime and idle for less than idleTimeout, and at
+// most maxIdle are kept idle per application server.
+type connPool struct {
+	mu sync.Mutex
+
+	idle       map[poolKey][]*pooledConn
+	checkedOut map[*gorfc.Connection]*pooledConn
+
+	maxIdle     int
+	maxLifetime time.Duration
+	idleTimeout time.Duration
+
+	created int64
+	reused  int64
+	errors  int64
+
+	stop chan struct{}
+}
+
+func newConnPool(maxIdle int, maxLifetime, idleTimeout time.Duration) *connPool {
+	if maxIdle <= 0 {
+		maxIdle = defaultPoolMaxIdle
+	}
+	if maxLifetime <= 0 {
+		maxLifetime = defaultPoolMaxLifetime
+	}
+	if idleTimeout <= 0 {
+		idleTimeout = defaultPoolIdleTimeout
+	}
+
+	p := &connPool{
+		idle:        make(map[poolKey][]*pooledConn),
+		checkedOut:  make(map[*gorfc.Connection]*pooledConn),
+		maxIdle:     maxIdle,
+		maxLifetime: maxLifetime,
+		idleTimeout: idleTimeout,
+		stop:        make(chan struct{}),
+	}
+	go p.reapIdle()
+	return p
+}
+
+// Get returns an idle connection for the given system/server if one is
+// available, still within its lifetime and not idle past idleTimeout,
+// otherwise it opens a new one.
+func (p *connPool) Get(system *systemInfo, server serverInfo, logger *slog.Logger) (*gorfc.Connection, error) {
+	key := poolKey{system: system.Name, server: server.name, sysnr: server.sysnr}
+
+	p.mu.Lock()
+	for len(p.idle[key]) > 0 {
+		n := len(p.idle[key])
+		pc := p.idle[key][n-1]
+		p.idle[key] = p.idle[key][:n-1]
+
+		if time.Since(pc.createdAt) > p.maxLifetime || time.Since(pc.idleSince) > p.idleTimeout {
+			p.mu.Unlock()
+			pc.conn.Close()
+			p.mu.Lock()
+			continue
+		}
+
+		p.checkedOut[pc.conn] = pc
+		p.reused++
+		p.mu.Unlock()
+		return pc.conn, nil
+	}
+	p.mu.Unlock()
+
+	c, err := connect(system, server, logger)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		p.errors++
+		return nil, err
+	}
+	p.created++
+	p.checkedOut[c] = &pooledConn{conn: c, key: key, createdAt: time.Now()}
+	return c, nil
+}
+
+// Put returns a successfully used connection to the pool, closing it
+// instead if the server's idle slots are already full.
+func (p *connPool) Put(c *gorfc.Connection) {
+	p.mu.Lock()
+	pc, ok := p.checkedOut[c]
+	delete(p.checkedOut, c)
+	if !ok {
+		p.mu.Unlock()
+		c.Close()
+		return
+	}
+
+	if len(p.idle[pc.key]) >= p.maxIdle {
+		p.mu.Unlock()
+		c.Close()
+		return
+	}
+
+	pc.idleSince = time.Now()
+	p.idle[pc.key] = append(p.idle[pc.key], pc)
+	p.mu.Unlock()
+}
+
+// Discard closes a connection that failed an RFC call instead of returning
+// it to the pool, since it can't be trusted for reuse.
+func (p *connPool) Discard(c *gorfc.Connection) {
+	p.mu.Lock()
+	delete(p.checkedOut, c)
+	p.mu.Unlock()
+	c.Close()
+}
+
+// reapIdle periodically closes idle connections that have been sitting
+// unused past idleTimeout.
+func (p *connPool) reapIdle() {
+	ticker := time.NewTicker(p.idleTimeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.reap()
+		}
+	}
+}
+
+func (p *connPool) reap() {
+	var stale []*pooledConn
+
+	p.mu.Lock()
+	for key, conns := range p.idle {
+		var fresh []*pooledConn
+		for _, pc := range conns {
+			if time.Since(pc.idleSince) > p.idleTimeout {
+				stale = append(stale, pc)
+				continue
+			}
+			fresh = append(fresh, pc)
+		}
+		p.idle[key] = fresh
+	}
+	p.mu.Unlock()
+
+	for _, pc := range stale {
+		pc.conn.Close()
+	}
+}
+
+// close shuts down the reaper and closes every idle connection. Used on
+// exporter shutdown.
+func (p *connPool) close() {
+	close(p.stop)
+
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = make(map[poolKey][]*pooledConn)
+	p.mu.Unlock()
+
+	for _, conns := range idle {
+		for _, pc := range conns {
+			pc.conn.Close()
+		}
+	}
+}
+
+// poolStats is a snapshot of connPool counters for the
+// sapnwrfc_pool_open/_reused_total/_created_total/_errors_total metrics.
+type poolStats struct {
+	open    int
+	reused  int64
+	created int64
+	errors  int64
+}
+
+// parseDuration parses a config duration string, falling back to 0 (which
+// tells newConnPool to use its package default) on empty or invalid input.
+func parseDuration(s string) time.Duration {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+func (p *connPool) stats() poolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	open := len(p.checkedOut)
+	for _, conns := range p.idle {
+		open += len(conns)
+	}
+
+	return poolStats{
+		open:    open,
+		reused:  p.reused,
+		created: p.created,
+		errors:  p.errors,
+	}
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sap/gorfc/gorfc"
+)
+
+// Get/Put/Discard/reap all eventually call *gorfc.Connection.Close, which
+// requires a real RFC logon from the SAP NW RFC SDK (cgo) and can't be
+// exercised here. These tests stick to the pool's pure bookkeeping - the
+// defaulting logic and the stats() aggregation - and stop the reaper
+// directly instead of calling close(), so no Close() call is ever made on
+// the placeholder connections below.
+
+func TestNewConnPoolAppliesDefaults(t *testing.T) {
+	p := newConnPool(0, 0, 0)
+	defer close(p.stop)
+
+	if p.maxIdle != defaultPoolMaxIdle {
+		t.Errorf("maxIdle = %v, want default %v", p.maxIdle, defaultPoolMaxIdle)
+	}
+	if p.maxLifetime != defaultPoolMaxLifetime {
+		t.Errorf("maxLifetime = %v, want default %v", p.maxLifetime, defaultPoolMaxLifetime)
+	}
+	if p.idleTimeout != defaultPoolIdleTimeout {
+		t.Errorf("idleTimeout = %v, want default %v", p.idleTimeout, defaultPoolIdleTimeout)
+	}
+}
+
+func TestNewConnPoolKeepsExplicitValues(t *testing.T) {
+	p := newConnPool(5, time.Minute, 2*time.Minute)
+	defer close(p.stop)
+
+	if p.maxIdle != 5 || p.maxLifetime != time.Minute || p.idleTimeout != 2*time.Minute {
+		t.Errorf("newConnPool(5, 1m, 2m) = %+v, want those exact values kept", p)
+	}
+}
+
+func TestConnPoolStatsCountsIdleAndCheckedOut(t *testing.T) {
+	p := newConnPool(2, time.Hour, time.Hour)
+	defer close(p.stop)
+
+	key := poolKey{system: "PRD", server: "srv01", sysnr: "00"}
+	idle := &gorfc.Connection{}
+	checkedOut := &gorfc.Connection{}
+
+	p.mu.Lock()
+	p.idle[key] = []*pooledConn{{conn: idle, key: key, createdAt: time.Now()}}
+	p.checkedOut[checkedOut] = &pooledConn{conn: checkedOut, key: key, createdAt: time.Now()}
+	p.created = 1
+	p.reused = 2
+	p.errors = 3
+	p.mu.Unlock()
+
+	got := p.stats()
+	want := poolStats{open: 2, created: 1, reused: 2, errors: 3}
+	if got != want {
+		t.Errorf("stats() = %+v, want %+v", got, want)
+	}
+}
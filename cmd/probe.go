@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// moduleInfo names a subset of TableMetrics so different Prometheus jobs can
+// probe the same target for different metrics, mirroring blackbox_exporter's
+// module concept.
+type moduleInfo struct {
+	Name         string   `toml:"name"`
+	TableMetrics []string `toml:"metrics"`
+}
+
+// probeHandler implements a multi-target /probe endpoint: given a target
+// system (and optionally a module), it builds a one-shot Config and returns
+// a fresh registry's exposition, so a single exporter instance can serve
+// systems that aren't part of its static config.
+func (config *Config) probeHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	target := params.Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+
+	system, err := config.probeSystem(target, params)
+	if err != nil {
+		config.logger.Error("Can't resolve probe target", "target", target, "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	metrics, err := config.probeMetrics(params.Get("module"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	probeConfig := &Config{
+		Secret:       config.Secret,
+		Systems:      []*systemInfo{system},
+		TableMetrics: metrics,
+		pool:         config.pool,
+		logger:       config.logger,
+	}
+
+	stats := func(recorder *scrapeRecorder) []metricData {
+		return probeConfig.collectMetrics(probeConfig.newScrapeContext(recorder))
+	}
+	numericStats := func(recorder *scrapeRecorder) []numericMetricData {
+		return nil
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(newCollector(stats, numericStats, nil))
+
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// probeSystem resolves the system to probe: a target already present in the
+// static config is reused as-is (password and servers already known from
+// appendMissingData); otherwise the request's own params build one ad hoc.
+// The password always comes from the encrypted Secret store, never from a
+// request parameter - /probe is unauthenticated, so accepting a plaintext
+// "password" param would turn it into an open relay that connects to any
+// host reachable from the exporter using any credentials a caller supplies,
+// and would leak the password into access logs and proxies via the query
+// string.
+func (config *Config) probeSystem(target string, params url.Values) (*systemInfo, error) {
+	for _, system := range config.Systems {
+		if strings.EqualFold(system.Name, target) {
+			return system, nil
+		}
+	}
+
+	system := &systemInfo{
+		Name:   target,
+		Server: params.Get("server"),
+		Sysnr:  params.Get("sysnr"),
+		User:   params.Get("user"),
+		Client: params.Get("client"),
+		Lang:   params.Get("lang"),
+	}
+
+	pw, err := config.lookupPassword(target)
+	if err != nil {
+		return nil, err
+	}
+	system.password = pw
+
+	if err := system.resolveServers(config.newScrapeContext(newScrapeRecorder())); err != nil {
+		return nil, err
+	}
+	return system, nil
+}
+
+// probeMetrics returns the TableMetrics belonging to the named module, or
+// every configured TableMetrics if no module was requested.
+func (config *Config) probeMetrics(module string) ([]*metricInfo, error) {
+	if module == "" {
+		return config.TableMetrics, nil
+	}
+
+	for _, m := range config.Modules {
+		if m.Name != module {
+			continue
+		}
+
+		byName := make(map[string]*metricInfo, len(config.TableMetrics))
+		for _, metric := range config.TableMetrics {
+			byName[metric.Name] = metric
+		}
+
+		var metrics []*metricInfo
+		for _, name := range m.TableMetrics {
+			if metric, ok := byName[name]; ok {
+				metrics = append(metrics, metric)
+			}
+		}
+		return metrics, nil
+	}
+
+	return nil, errors.Errorf("unknown module %q", module)
+}
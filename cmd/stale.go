@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"sync"
+	"time"
+)
+
+// staleKey identifies one (metric, system, server) series whose last good
+// value may need to be re-emitted after a failed scrape.
+type staleKey struct {
+	metric string
+	system string
+	server string
+}
+
+type staleEntry struct {
+	stats []statData
+	at    time.Time
+}
+
+// staleCache remembers the last successfully observed statData for a
+// metric so a transient RFC failure re-emits it instead of letting the
+// series disappear, which would otherwise reset any rate()/increase() over
+// it in Prometheus. Borrowed from statsd_exporter's TTL idea.
+type staleCache struct {
+	mu      sync.Mutex
+	entries map[staleKey]staleEntry
+}
+
+func newStaleCache() *staleCache {
+	return &staleCache{entries: make(map[staleKey]staleEntry)}
+}
+
+// get returns the cached stats for key if they exist and are younger than
+// ttl, along with their age. A ttl of 0 disables caching entirely.
+func (c *staleCache) get(key staleKey, ttl time.Duration) ([]statData, time.Duration, bool) {
+	if c == nil || ttl <= 0 {
+		return nil, 0, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, 0, false
+	}
+
+	age := time.Since(e.at)
+	if age > ttl {
+		delete(c.entries, key)
+		return nil, 0, false
+	}
+	return e.stats, age, true
+}
+
+// put stores a freshly observed value, replacing any previous one.
+func (c *staleCache) put(key staleKey, stats []statData, ttl time.Duration) {
+	if c == nil || ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = staleEntry{stats: stats, at: time.Now()}
+}
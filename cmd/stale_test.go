@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStaleCacheGetMissingKey(t *testing.T) {
+	c := newStaleCache()
+
+	if _, _, ok := c.get(staleKey{system: "PRD"}, time.Minute); ok {
+		t.Errorf("get() on an empty cache returned ok=true")
+	}
+}
+
+func TestStaleCacheZeroTTLDisablesCaching(t *testing.T) {
+	c := newStaleCache()
+	key := staleKey{system: "PRD", server: "srv01", metric: "resp_time"}
+	stats := []statData{{value: 1}}
+
+	c.put(key, stats, 0)
+	if _, _, ok := c.get(key, time.Minute); ok {
+		t.Errorf("get() found a value stored with ttl=0, want put() to be a no-op")
+	}
+
+	c.put(key, stats, time.Minute)
+	if _, _, ok := c.get(key, 0); ok {
+		t.Errorf("get() with ttl=0 returned ok=true, want caching disabled regardless of what's stored")
+	}
+}
+
+func TestStaleCacheRoundTripWithinTTL(t *testing.T) {
+	c := newStaleCache()
+	key := staleKey{system: "PRD", server: "srv01", metric: "resp_time"}
+	want := []statData{{value: 42, labels: []string{"system"}, labelValues: []string{"PRD"}}}
+
+	c.put(key, want, time.Hour)
+
+	got, age, ok := c.get(key, time.Hour)
+	if !ok {
+		t.Fatalf("get() = ok=false, want a cached value")
+	}
+	if len(got) != 1 || got[0].value != 42 {
+		t.Errorf("get() = %v, want %v", got, want)
+	}
+	if age < 0 || age > time.Second {
+		t.Errorf("get() age = %v, want a small non-negative duration", age)
+	}
+}
+
+func TestStaleCacheEvictsExpiredEntry(t *testing.T) {
+	c := newStaleCache()
+	key := staleKey{system: "PRD"}
+	c.put(key, []statData{{value: 1}}, time.Millisecond)
+
+	time.Sleep(5 * time.Millisecond)
+
+	if _, _, ok := c.get(key, time.Millisecond); ok {
+		t.Errorf("get() returned an entry past its TTL")
+	}
+	if _, ok := c.entries[key]; ok {
+		t.Errorf("get() left an expired entry in place instead of evicting it")
+	}
+}
+
+func TestStaleCachePutReplacesPreviousValue(t *testing.T) {
+	c := newStaleCache()
+	key := staleKey{system: "PRD"}
+
+	c.put(key, []statData{{value: 1}}, time.Hour)
+	c.put(key, []statData{{value: 2}}, time.Hour)
+
+	got, _, ok := c.get(key, time.Hour)
+	if !ok || len(got) != 1 || got[0].value != 2 {
+		t.Errorf("get() = %v, want the latest put() value [2]", got)
+	}
+}